@@ -1,38 +1,123 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jrallison/go-workers"
+
+	rikkilog "github.com/MalloZup/rikki-/log"
+	"github.com/MalloZup/rikki-/metrics"
 )
 
+// logger is the structured, leveled logger shared by the analyzer. process
+// tags its events with the submission UUID, track ID and processing stage
+// (via rikkilog.With), so failures against analysseur and exercism can be
+// correlated in production.
+var logger = rikkilog.New(os.Stdout, rikkilog.Info)
+
+// defaultLocale is used when a solution carries no locale preference, and
+// as the fallback when a track has no comment translated into the user's
+// locale.
+const defaultLocale = "en"
+
 // Analyzer is a job that provides feedback on specific issues in the code.
 // The job receives the uuid of a submission, calls the exercism API to get
-// the code, submits the code to analysseur for static analysis, and then,
-// based on the results, chooses a response to submit as a comment from rikki-
-// back to the conversation on exercism.
+// the code, submits the code to the track's analysis backend, and then,
+// based on the results, chooses a response to submit as a comment from
+// rikki- back to the conversation on exercism.
 type Analyzer struct {
 	exercism       *Exercism
 	analysseurHost string
-	comments       map[string][]byte
+	config         *Config
+	comments       map[string]map[string]map[string]*comment
+	retryPolicy    RetryPolicy
 }
 
-// NewAnalyzer configures an analyzer job to talk to the exercism and analysseur APIs.
-func NewAnalyzer(exercism *Exercism, analysseur, dir string) (*Analyzer, error) {
-	dir = filepath.Join(dir, "analyzer", "ruby")
+// NewAnalyzer configures an analyzer job to talk to the exercism API and to
+// the per-track analysis backends described by the config at configPath.
+// Comment sets are loaded from analyzer/<track>/ for every enabled track.
+// Transient failures talking to either API are retried per DefaultRetryPolicy;
+// use NewAnalyzerWithRetryPolicy to override it.
+func NewAnalyzer(exercism *Exercism, analysseur, configPath, dir string) (*Analyzer, error) {
+	return NewAnalyzerWithRetryPolicy(exercism, analysseur, configPath, dir, DefaultRetryPolicy)
+}
+
+// NewAnalyzerWithRetryPolicy is like NewAnalyzer but lets the caller
+// override the backoff used for retrying transient analysseur/exercism
+// failures, which is useful in tests.
+func NewAnalyzerWithRetryPolicy(exercism *Exercism, analysseur, configPath, dir string, retryPolicy RetryPolicy) (*Analyzer, error) {
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make(map[string]map[string]map[string]*comment)
+	for _, track := range config.enabledTracks() {
+		trackComments, err := loadTrackComments(filepath.Join(dir, "analyzer", track))
+		if err != nil {
+			return nil, err
+		}
+		comments[track] = trackComments
+	}
+
+	return &Analyzer{
+		exercism:       exercism,
+		analysseurHost: analysseur,
+		config:         config,
+		comments:       comments,
+		retryPolicy:    retryPolicy,
+	}, nil
+}
+
+// loadTrackComments walks dir (analyzer/<track>), which is expected to
+// contain one subdirectory per locale (analyzer/<track>/<locale>/<smell>.md),
+// and returns comments keyed by locale and then by smell.
+func loadTrackComments(dir string) (map[string]map[string]*comment, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	comments := make(map[string]map[string]*comment)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
 
-	comments := make(map[string][]byte)
+		locale := entry.Name()
+		localeComments, err := loadComments(filepath.Join(dir, locale))
+		if err != nil {
+			return nil, err
+		}
+		comments[locale] = localeComments
+	}
+
+	if len(comments) == 0 {
+		logger.Warn("no locale subdirectories found - expected analyzer/<track>/<locale>/<smell>.md", rikkilog.Fields{"dir": dir})
+	}
+
+	return comments, nil
+}
+
+// loadComments walks dir, keyed on the comment's path relative to dir with
+// its .md extension stripped, e.g. "readability/variable_names". Each file
+// may carry front matter biasing its selection weight and cooldown; see
+// parseComment.
+func loadComments(dir string) (map[string]*comment, error) {
+	comments := make(map[string]*comment)
 
 	fn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
 		if info.IsDir() {
 			return nil
 		}
@@ -44,7 +129,12 @@ func NewAnalyzer(exercism *Exercism, analysseur, dir string) (*Analyzer, error)
 		key := r.Replace(path)
 		key = strings.TrimLeft(key, "/")
 
-		comments[key] = b
+		c, err := parseComment(b)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+
+		comments[key] = c
 
 		return nil
 	}
@@ -53,37 +143,106 @@ func NewAnalyzer(exercism *Exercism, analysseur, dir string) (*Analyzer, error)
 		return nil, err
 	}
 
-	return &Analyzer{
-		exercism:       exercism,
-		analysseurHost: analysseur,
-		comments:       comments,
-	}, nil
+	return comments, nil
 }
 
-type analysisResult struct {
-	Type string   `json:"type"`
-	Keys []string `json:"keys"`
+// seedFromUUID derives a reproducible rand seed from a submission UUID, so
+// comment selection for a given submission can be replayed deterministically
+// in tests without sharing a *rand.Rand across concurrent jobs.
+func seedFromUUID(uuid string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(uuid))
+	return int64(h.Sum64())
 }
-type analysisPayload struct {
-	Results []analysisResult `json:"results"`
-	Error   string           `json:"error"`
+
+// selectComment picks a weighted-random comment out of the comments that
+// match smells (the order of smells gives no priority), skipping any still on cooldown
+// for user per checkCooldown (production callers pass onCooldown; tests can
+// stub it out). Comments are looked up in locale first, falling back to
+// defaultLocale when a track has no translation for a given smell. It
+// returns the chosen smell key and comment, or ("", nil) if nothing is
+// eligible.
+func (analyzer *Analyzer) selectComment(rnd *rand.Rand, track, locale, user string, smells []string, checkCooldown func(user, smell string) (bool, error)) (string, *comment) {
+	type candidate struct {
+		smell   string
+		comment *comment
+	}
+
+	var candidates []candidate
+	total := 0
+	for _, smell := range smells {
+		c := analyzer.comments[track][locale][smell]
+		if c == nil {
+			c = analyzer.comments[track][defaultLocale][smell]
+		}
+		if c == nil {
+			continue
+		}
+
+		// weight <= 0 (e.g. an operator writing "weight: 0" in front
+		// matter) disables a comment rather than crashing selection.
+		if c.Weight <= 0 {
+			continue
+		}
+
+		on, err := checkCooldown(user, smell)
+		if err != nil {
+			logger.Warn("failed to check cooldown", rikkilog.Fields{"user": user, "smell": smell, "error": err.Error()})
+		} else if on {
+			continue
+		}
+
+		candidates = append(candidates, candidate{smell, c})
+		total += c.Weight
+	}
+
+	if len(candidates) == 0 || total <= 0 {
+		return "", nil
+	}
+
+	pick := rnd.Intn(total)
+	for _, cand := range candidates {
+		pick -= cand.comment.Weight
+		if pick < 0 {
+			return cand.smell, cand.comment
+		}
+	}
+
+	last := candidates[len(candidates)-1]
+	return last.smell, last.comment
 }
 
 func (analyzer *Analyzer) process(msg *workers.Msg) {
 	uuid, err := msg.Args().GetIndex(0).String()
 	if err != nil {
-		lgr.Printf("unable to determine submission key - %s\n", err)
+		logger.Error("unable to determine submission key", rikkilog.With("", "", "fetch_solution").And(rikkilog.Fields{"error": err.Error()}))
 		return
 	}
 
-	solution, err := analyzer.exercism.FetchSolution(uuid)
+	fetchStart := time.Now()
+	var solution *Solution
+	err = doRetriable(analyzer.retryPolicy, func() error {
+		var ferr error
+		solution, ferr = analyzer.exercism.FetchSolution(uuid)
+		return ferr
+	})
+	metrics.ExercismRequestSeconds.WithLabelValues("fetch_solution").Observe(time.Since(fetchStart).Seconds())
 	if err != nil {
-		lgr.Printf("%s\n", err)
+		logger.Error("giving up after retries", rikkilog.With(uuid, "", "fetch_solution").And(rikkilog.Fields{"error": err.Error()}))
+		metrics.JobsTotal.WithLabelValues("fetch_solution", "", "error").Inc()
+		panic(err) // let go-workers' own retry middleware re-enqueue the job
+	}
+
+	if !analyzer.config.IsEnabled(solution.TrackID) {
+		logger.Info("skipping - rikki- doesn't support this track", rikkilog.With(uuid, solution.TrackID, "fetch_solution"))
+		metrics.JobsTotal.WithLabelValues("fetch_solution", solution.TrackID, "skipped").Inc()
 		return
 	}
 
-	if solution.TrackID != "ruby" {
-		lgr.Printf("skipping - rikki- doesn't support %s\n", solution.TrackID)
+	backend, ok := backendFor(solution.TrackID)
+	if !ok {
+		logger.Error("skipping - no analysis backend registered", rikkilog.With(uuid, solution.TrackID, "analysseur_request"))
+		metrics.JobsTotal.WithLabelValues("analysseur_request", solution.TrackID, "skipped").Inc()
 		return
 	}
 
@@ -92,91 +251,82 @@ func (analyzer *Analyzer) process(msg *workers.Msg) {
 		sources = append(sources, source)
 	}
 
-	// Step 2: submit code to analysseur
-	url := fmt.Sprintf("%s/analyze/%s", analyzer.analysseurHost, solution.TrackID)
-	codeBody := struct {
-		Code string `json:"code"`
-	}{
-		strings.Join(sources, "\n"),
-	}
-	codeBodyJSON, err := json.Marshal(codeBody)
-	if err != nil {
-		lgr.Printf("%s - %s\n", uuid, err)
-		return
+	// Step 2: submit code to the track's analysis backend
+	host := analyzer.config.Tracks[solution.TrackID].Analysseur
+	if host == "" {
+		host = analyzer.analysseurHost
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewReader(codeBodyJSON))
+	analysseurStart := time.Now()
+	resp, err := doWithRetry(http.DefaultClient, analyzer.retryPolicy, func() (*http.Request, error) {
+		return backend.BuildRequest(host, sources)
+	})
+	metrics.AnalysseurRequestSeconds.WithLabelValues(solution.TrackID).Observe(time.Since(analysseurStart).Seconds())
 	if err != nil {
-		lgr.Printf("%s - cannot prepare request to %s - %s\n", uuid, url, err)
-		return
-	}
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		lgr.Printf("%s - request to %s failed - %s\n", uuid, url, err)
-		return
+		logger.Error("giving up after retries", rikkilog.With(uuid, solution.TrackID, "analysseur_request").And(rikkilog.Fields{"host": host, "error": err.Error()}))
+		metrics.JobsTotal.WithLabelValues("analysseur_request", solution.TrackID, "error").Inc()
+		panic(err) // let go-workers' own retry middleware re-enqueue the job
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		lgr.Printf("%s - failed to fetch submission - %s\n", uuid, err)
+		logger.Error("failed to read analysis response", rikkilog.With(uuid, solution.TrackID, "analysseur_request").And(rikkilog.Fields{"error": err.Error()}))
+		metrics.JobsTotal.WithLabelValues("analysseur_request", solution.TrackID, "error").Inc()
 		return
 	}
 	resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		lgr.Printf("%s - %s responded with status %d - %s\n", uuid, url, resp.StatusCode, string(body))
+		logger.Error("analysis backend responded with an error", rikkilog.With(uuid, solution.TrackID, "analysseur_request").And(rikkilog.Fields{"host": host, "status": resp.StatusCode, "body": string(body)}))
+		metrics.JobsTotal.WithLabelValues("analysseur_request", solution.TrackID, "error").Inc()
 		return
 	}
 
-	var ap analysisPayload
-	err = json.Unmarshal(body, &ap)
+	smells, err := backend.ParseSmells(body)
 	if err != nil {
-		lgr.Printf("%s - %s\n", uuid, err)
-		return
-	}
-
-	if ap.Error != "" {
-		lgr.Printf("analysis api is complaining about %s - %s\n", uuid, ap.Error)
+		logger.Error(err.Error(), rikkilog.With(uuid, solution.TrackID, "analysseur_request"))
+		metrics.JobsTotal.WithLabelValues("analysseur_request", solution.TrackID, "error").Inc()
 		return
 	}
+	metrics.JobsTotal.WithLabelValues("analysseur_request", solution.TrackID, "ok").Inc()
 
-	if len(ap.Results) == 0 {
+	if len(smells) == 0 {
 		// no feedback, bailing
 		return
 	}
 
-	var smells []string
-	sanity := log.New(os.Stdout, "SANITY: ", log.Ldate|log.Ltime|log.Lshortfile)
-	for _, result := range ap.Results {
-		for _, key := range result.Keys {
-			sanity.Printf("%s : %s - %s\n", uuid, result.Type, key)
-
-			smells = append(smells, filepath.Join(result.Type, key))
-		}
+	locale := solution.Locale
+	if locale == "" {
+		locale = defaultLocale
 	}
 
-	// shuffle code smells
-	for i := range smells {
-		j := rand.Intn(i + 1)
-		smells[i], smells[j] = smells[j], smells[i]
+	rnd := rand.New(rand.NewSource(seedFromUUID(uuid)))
+	postedSmell, chosen := analyzer.selectComment(rnd, solution.TrackID, locale, solution.UserName, smells, onCooldown)
+	if chosen == nil {
+		return
 	}
 
-	// return the first available comment
-	var comment []byte
-	for _, smell := range smells {
-		b := analyzer.comments[smell]
+	rendered := renderComment(chosen, commentContext{
+		User:     solution.UserName,
+		Exercise: solution.Exercise,
+		Smell:    postedSmell,
+	})
 
-		if len(b) > 0 {
-			comment = b
-			break
-		}
+	// Step 3: submit chosen comment to exercism.io api
+	submitStart := time.Now()
+	err = doRetriable(analyzer.retryPolicy, func() error {
+		return analyzer.exercism.SubmitComment(rendered, uuid)
+	})
+	metrics.ExercismRequestSeconds.WithLabelValues("submit_comment").Observe(time.Since(submitStart).Seconds())
+	if err != nil {
+		logger.Error("giving up after retries", rikkilog.With(uuid, solution.TrackID, "submit_comment").And(rikkilog.Fields{"error": err.Error()}))
+		metrics.JobsTotal.WithLabelValues("submit_comment", solution.TrackID, "error").Inc()
+		panic(err) // let go-workers' own retry middleware re-enqueue the job
 	}
 
-	if len(comment) == 0 {
-		return
-	}
+	metrics.JobsTotal.WithLabelValues("submit_comment", solution.TrackID, "ok").Inc()
+	metrics.CommentsPostedTotal.WithLabelValues(postedSmell).Inc()
 
-	// Step 3: submit random comment to exercism.io api
-	if err := analyzer.exercism.SubmitComment(comment, uuid); err != nil {
-		lgr.Printf("%s\n", err)
+	if err := markCooldown(solution.UserName, postedSmell, chosen.Cooldown); err != nil {
+		logger.Warn("failed to persist comment cooldown", rikkilog.With(uuid, solution.TrackID, "submit_comment").And(rikkilog.Fields{"error": err.Error()}))
 	}
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func noCooldown(user, smell string) (bool, error) { return false, nil }
+
+func TestSelectCommentPicksEligibleSmell(t *testing.T) {
+	analyzer := &Analyzer{
+		comments: map[string]map[string]map[string]*comment{
+			"ruby": {
+				"en": {
+					"readability/variable_names": {Weight: 1, Body: []byte("rename me")},
+				},
+			},
+		},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	smell, c := analyzer.selectComment(rnd, "ruby", "en", "alice", []string{"readability/variable_names"}, noCooldown)
+
+	if smell != "readability/variable_names" {
+		t.Errorf("smell = %q, want readability/variable_names", smell)
+	}
+	if c == nil || string(c.Body) != "rename me" {
+		t.Errorf("comment = %+v", c)
+	}
+}
+
+func TestSelectCommentFallsBackToDefaultLocale(t *testing.T) {
+	analyzer := &Analyzer{
+		comments: map[string]map[string]map[string]*comment{
+			"ruby": {
+				defaultLocale: {
+					"readability/variable_names": {Weight: 1, Body: []byte("rename me")},
+				},
+			},
+		},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	smell, c := analyzer.selectComment(rnd, "ruby", "pt-BR", "alice", []string{"readability/variable_names"}, noCooldown)
+
+	if smell != "readability/variable_names" || c == nil {
+		t.Errorf("expected fallback to defaultLocale comment, got smell=%q c=%+v", smell, c)
+	}
+}
+
+func TestSelectCommentSkipsZeroWeightComment(t *testing.T) {
+	analyzer := &Analyzer{
+		comments: map[string]map[string]map[string]*comment{
+			"ruby": {
+				"en": {
+					"readability/variable_names": {Weight: 0, Body: []byte("disabled")},
+				},
+			},
+		},
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	smell, c := analyzer.selectComment(rnd, "ruby", "en", "alice", []string{"readability/variable_names"}, noCooldown)
+
+	if smell != "" || c != nil {
+		t.Errorf("expected a disabled (weight <= 0) comment to be skipped, got smell=%q c=%+v", smell, c)
+	}
+}
+
+func TestSelectCommentSkipsCommentOnCooldown(t *testing.T) {
+	analyzer := &Analyzer{
+		comments: map[string]map[string]map[string]*comment{
+			"ruby": {
+				"en": {
+					"readability/variable_names": {Weight: 1, Body: []byte("rename me")},
+				},
+			},
+		},
+	}
+
+	onCooldown := func(user, smell string) (bool, error) { return true, nil }
+
+	rnd := rand.New(rand.NewSource(1))
+	smell, c := analyzer.selectComment(rnd, "ruby", "en", "alice", []string{"readability/variable_names"}, onCooldown)
+
+	if smell != "" || c != nil {
+		t.Errorf("expected comment on cooldown to be skipped, got smell=%q c=%+v", smell, c)
+	}
+}
+
+func TestSelectCommentReturnsNilWhenNothingEligible(t *testing.T) {
+	analyzer := &Analyzer{comments: map[string]map[string]map[string]*comment{}}
+
+	rnd := rand.New(rand.NewSource(1))
+	smell, c := analyzer.selectComment(rnd, "ruby", "en", "alice", []string{"readability/variable_names"}, noCooldown)
+
+	if smell != "" || c != nil {
+		t.Errorf("expected no eligible comment, got smell=%q c=%+v", smell, c)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// LanguageBackend knows how to build an analysis request for a specific
+// track and how to parse whatever shape of response the analysis service
+// returns for it. New tracks register their own backend instead of
+// teaching process about their wire format.
+type LanguageBackend interface {
+	// Name returns the track ID this backend handles, e.g. "ruby".
+	Name() string
+
+	// BuildRequest returns the HTTP request to submit sources for analysis
+	// against the given analysseur host.
+	BuildRequest(host string, sources []string) (*http.Request, error)
+
+	// ParseSmells extracts the list of code smells (as comment lookup keys,
+	// e.g. "readability/variable_names") from a raw response body.
+	ParseSmells(body []byte) ([]string, error)
+}
+
+var backendRegistry = map[string]LanguageBackend{}
+
+// RegisterBackend makes a LanguageBackend available for its track. It is
+// meant to be called from an init() function.
+func RegisterBackend(b LanguageBackend) {
+	backendRegistry[b.Name()] = b
+}
+
+func backendFor(track string) (LanguageBackend, bool) {
+	b, ok := backendRegistry[track]
+	return b, ok
+}
+
+func init() {
+	RegisterBackend(&rubyBackend{})
+}
+
+// rubyBackend talks to analysseur, the static analyzer rikki- has
+// historically used for the ruby track.
+type rubyBackend struct{}
+
+func (b *rubyBackend) Name() string { return "ruby" }
+
+func (b *rubyBackend) BuildRequest(host string, sources []string) (*http.Request, error) {
+	url := fmt.Sprintf("%s/analyze/%s", host, b.Name())
+
+	codeBody := struct {
+		Code string `json:"code"`
+	}{
+		strings.Join(sources, "\n"),
+	}
+	codeBodyJSON, err := json.Marshal(codeBody)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.NewRequest("POST", url, bytes.NewReader(codeBodyJSON))
+}
+
+type rubyAnalysisResult struct {
+	Type string   `json:"type"`
+	Keys []string `json:"keys"`
+}
+type rubyAnalysisPayload struct {
+	Results []rubyAnalysisResult `json:"results"`
+	Error   string               `json:"error"`
+}
+
+func (b *rubyBackend) ParseSmells(body []byte) ([]string, error) {
+	var ap rubyAnalysisPayload
+	if err := json.Unmarshal(body, &ap); err != nil {
+		return nil, err
+	}
+
+	if ap.Error != "" {
+		return nil, fmt.Errorf("analysis api is complaining - %s", ap.Error)
+	}
+
+	var smells []string
+	for _, result := range ap.Results {
+		for _, key := range result.Keys {
+			smells = append(smells, filepath.Join(result.Type, key))
+		}
+	}
+
+	return smells, nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// comment is a single markdown comment rikki- can post, optionally biased
+// by front-matter weight and guarded by a cooldown so it isn't repeated too
+// often for the same user, e.g.:
+//
+//	---
+//	weight: 3
+//	cooldown: 168h
+//	---
+//	Looks like you could extract a method here...
+//
+// Body may also reference .User, .Exercise and .Smell via text/template
+// actions to personalize the comment; tmpl is nil when Body doesn't parse
+// as one (e.g. existing comments with literal curly braces), in which case
+// it's posted verbatim.
+type comment struct {
+	Body     []byte
+	Weight   int
+	Cooldown time.Duration
+	tmpl     *template.Template
+}
+
+// defaultWeight is used for comments without a weight in their front matter.
+const defaultWeight = 1
+
+const frontMatterDelim = "---\n"
+
+// parseComment splits the optional front matter off of raw and returns the
+// parsed comment. A comment without front matter gets the default weight
+// and no cooldown.
+func parseComment(raw []byte) (*comment, error) {
+	c := &comment{Weight: defaultWeight, Body: raw}
+
+	compile := func() *comment {
+		if tmpl, err := template.New("comment").Parse(string(c.Body)); err == nil {
+			c.tmpl = tmpl
+		}
+		return c
+	}
+
+	if !bytes.HasPrefix(raw, []byte(frontMatterDelim)) {
+		return compile(), nil
+	}
+
+	rest := raw[len(frontMatterDelim):]
+	end := bytes.Index(rest, []byte(frontMatterDelim))
+	if end == -1 {
+		return compile(), nil
+	}
+
+	c.Body = rest[end+len(frontMatterDelim):]
+
+	for _, line := range strings.Split(string(rest[:end]), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+
+		switch key {
+		case "weight":
+			w, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid weight %q: %s", value, err)
+			}
+			c.Weight = w
+		case "cooldown":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cooldown %q: %s", value, err)
+			}
+			c.Cooldown = d
+		}
+	}
+
+	return compile(), nil
+}
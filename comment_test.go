@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestParseCommentWithoutFrontMatter(t *testing.T) {
+	c, err := parseComment([]byte("Looks like you could extract a method here.\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if c.Weight != defaultWeight {
+		t.Errorf("Weight = %d, want default %d", c.Weight, defaultWeight)
+	}
+	if c.Cooldown != 0 {
+		t.Errorf("Cooldown = %v, want 0", c.Cooldown)
+	}
+	if string(c.Body) != "Looks like you could extract a method here.\n" {
+		t.Errorf("Body = %q", c.Body)
+	}
+}
+
+func TestParseCommentWithFrontMatter(t *testing.T) {
+	raw := []byte("---\nweight: 3\ncooldown: 168h\n---\nConsider a guard clause here.\n")
+
+	c, err := parseComment(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if c.Weight != 3 {
+		t.Errorf("Weight = %d, want 3", c.Weight)
+	}
+	if c.Cooldown != 168*time.Hour {
+		t.Errorf("Cooldown = %v, want 168h", c.Cooldown)
+	}
+	if !bytes.Equal(c.Body, []byte("Consider a guard clause here.\n")) {
+		t.Errorf("Body = %q", c.Body)
+	}
+}
+
+func TestParseCommentRejectsInvalidWeight(t *testing.T) {
+	_, err := parseComment([]byte("---\nweight: not-a-number\n---\nbody\n"))
+	if err == nil {
+		t.Fatal("expected an error for a non-numeric weight")
+	}
+}
+
+func TestParseCommentRejectsInvalidCooldown(t *testing.T) {
+	_, err := parseComment([]byte("---\ncooldown: not-a-duration\n---\nbody\n"))
+	if err == nil {
+		t.Fatal("expected an error for an invalid cooldown")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// TrackConfig describes how the analyzer should reach the analysis backend
+// for a single track.
+type TrackConfig struct {
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+	Analysseur string `json:"analysseur" yaml:"analysseur"`
+}
+
+// Config is the top level analyzer configuration, keyed by track ID.
+type Config struct {
+	Tracks map[string]TrackConfig `json:"tracks" yaml:"tracks"`
+}
+
+// LoadConfig reads a tracks configuration from path. The format (YAML or
+// JSON) is picked from the file extension, defaulting to YAML.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(b, cfg)
+	} else {
+		err = yaml.Unmarshal(b, cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// enabledTracks returns the track IDs configured as enabled.
+func (c *Config) enabledTracks() []string {
+	var tracks []string
+	for track, t := range c.Tracks {
+		if t.Enabled {
+			tracks = append(tracks, track)
+		}
+	}
+	return tracks
+}
+
+// IsEnabled reports whether track is configured and turned on.
+func (c *Config) IsEnabled(track string) bool {
+	return c.Tracks[track].Enabled
+}
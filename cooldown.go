@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/jrallison/go-workers"
+)
+
+// cooldownKey is the redis key tracking whether smell was already posted to
+// user recently. It lives in the same redis instance go-workers uses.
+func cooldownKey(user, smell string) string {
+	return fmt.Sprintf("rikki:cooldown:%s:%s", user, smell)
+}
+
+// onCooldown reports whether smell is still within its cooldown window for
+// user.
+func onCooldown(user, smell string) (bool, error) {
+	conn := workers.Config.Pool.Get()
+	defer conn.Close()
+
+	return redis.Bool(conn.Do("EXISTS", cooldownKey(user, smell)))
+}
+
+// markCooldown records that smell was just posted to user, so it won't be
+// picked again until d passes. It survives restarts since it's in redis.
+func markCooldown(user, smell string, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	conn := workers.Config.Pool.Get()
+	defer conn.Close()
+
+	_, err := conn.Do("SET", cooldownKey(user, smell), 1, "EX", int(d.Seconds()))
+	return err
+}
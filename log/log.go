@@ -0,0 +1,109 @@
+// Package log is a small structured, leveled logger shared by rikki-'s
+// analyzer, exercism client and commenter, so that failures talking to the
+// two external services it depends on can be correlated by submission UUID,
+// track and stage across a single JSON-lines output.
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log line.
+type Level int
+
+// Levels, in increasing order of severity.
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+func (l Level) String() string {
+	switch l {
+	case Debug:
+		return "debug"
+	case Info:
+		return "info"
+	case Warn:
+		return "warn"
+	case Error:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Fields are the structured key/value pairs attached to a log line, e.g.
+// the submission UUID, track ID and processing stage.
+type Fields map[string]interface{}
+
+// Logger writes leveled, structured events as JSON lines to an io.Writer.
+// It is safe for concurrent use.
+type Logger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level Level
+}
+
+// New returns a Logger that writes to out, filtering out anything below
+// level.
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{out: out, level: level}
+}
+
+type event struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Fields  Fields    `json:"fields,omitempty"`
+}
+
+func (l *Logger) log(level Level, fields Fields, msg string) {
+	if level < l.level {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	enc := json.NewEncoder(l.out)
+	_ = enc.Encode(event{
+		Time:    time.Now(),
+		Level:   level.String(),
+		Message: msg,
+		Fields:  fields,
+	})
+}
+
+// Debug logs a debug-level event.
+func (l *Logger) Debug(msg string, fields Fields) { l.log(Debug, fields, msg) }
+
+// Info logs an info-level event.
+func (l *Logger) Info(msg string, fields Fields) { l.log(Info, fields, msg) }
+
+// Warn logs a warn-level event.
+func (l *Logger) Warn(msg string, fields Fields) { l.log(Warn, fields, msg) }
+
+// Error logs an error-level event.
+func (l *Logger) Error(msg string, fields Fields) { l.log(Error, fields, msg) }
+
+// With returns Fields pre-populated with the correlation identifiers every
+// rikki- log line should carry: the submission UUID, its track, and the
+// processing stage that produced the event. Extra fields, e.g. an
+// underlying error, can be folded in with Fields.And.
+func With(uuid, track, stage string) Fields {
+	return Fields{"uuid": uuid, "track": track, "stage": stage}
+}
+
+// And returns f with extra's keys folded in, for attaching ad-hoc details
+// (like an error message) to the correlation fields from With.
+func (f Fields) And(extra Fields) Fields {
+	for k, v := range extra {
+		f[k] = v
+	}
+	return f
+}
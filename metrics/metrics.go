@@ -0,0 +1,64 @@
+// Package metrics holds the Prometheus series rikki-'s job pipeline
+// exposes on /metrics, and a small registry so future job types (spelling,
+// style, ...) can add their own series without editing the analyzer.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// JobsTotal counts processed jobs by stage, track and result
+	// ("ok", "error", "skipped").
+	JobsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rikki_jobs_total",
+		Help: "Total number of analyzer jobs processed, by stage, track and result.",
+	}, []string{"stage", "track", "result"})
+
+	// AnalysseurRequestSeconds tracks how long requests to analysseur take.
+	AnalysseurRequestSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rikki_analysseur_request_seconds",
+		Help:    "Latency of requests to the analysseur static analysis backend.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"track"})
+
+	// ExercismRequestSeconds tracks how long calls to the exercism API take.
+	ExercismRequestSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rikki_exercism_request_seconds",
+		Help:    "Latency of requests to the exercism API.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"stage"})
+
+	// CommentsPostedTotal counts comments posted back to exercism, by smell.
+	CommentsPostedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rikki_comments_posted_total",
+		Help: "Total number of comments posted back to exercism, by smell.",
+	}, []string{"smell"})
+
+	// QueueDepth reports the current go-workers queue depth.
+	QueueDepth = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "rikki_queue_depth",
+		Help: "Current depth of the analyzer job queue.",
+	})
+)
+
+func init() {
+	Register(JobsTotal, AnalysseurRequestSeconds, ExercismRequestSeconds, CommentsPostedTotal, QueueDepth)
+}
+
+// Register adds collectors to the default Prometheus registry, so that
+// future job types can expose their own series from wherever they're
+// defined.
+func Register(collectors ...prometheus.Collector) {
+	for _, c := range collectors {
+		prometheus.MustRegister(c)
+	}
+}
+
+// Handler returns the http.Handler to mount at /metrics.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/jrallison/go-workers"
+
+	rikkilog "github.com/MalloZup/rikki-/log"
+	"github.com/MalloZup/rikki-/metrics"
+)
+
+// ServeMetrics mounts /metrics on addr and starts a background sampler that
+// keeps the queue depth gauge for queue fresh every interval. It blocks, so
+// callers should run it in its own goroutine.
+func ServeMetrics(addr, queue string, interval time.Duration) error {
+	go watchQueueDepth(queue, interval)
+
+	http.Handle("/metrics", metrics.Handler())
+	return http.ListenAndServe(addr, nil)
+}
+
+// watchQueueDepth polls the depth of queue's go-workers (Sidekiq-compatible)
+// redis list on every tick and reports it on metrics.QueueDepth.
+func watchQueueDepth(queue string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		conn := workers.Config.Pool.Get()
+		depth, err := redis.Int64(conn.Do("LLEN", "queue:"+queue))
+		conn.Close()
+		if err != nil {
+			logger.Warn("failed to sample queue depth", rikkilog.Fields{"queue": queue, "error": err.Error()})
+			continue
+		}
+
+		metrics.QueueDepth.Set(float64(depth))
+	}
+}
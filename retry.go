@@ -0,0 +1,173 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures the exponential backoff with jitter used to retry
+// transient failures talking to analysseur or exercism.
+type RetryPolicy struct {
+	Base       time.Duration
+	Factor     float64
+	Cap        time.Duration
+	MaxRetries int
+}
+
+// DefaultRetryPolicy is the backoff rikki- uses unless a caller overrides it.
+var DefaultRetryPolicy = RetryPolicy{
+	Base:       500 * time.Millisecond,
+	Factor:     2,
+	Cap:        30 * time.Second,
+	MaxRetries: 5,
+}
+
+// backoff returns how long to sleep before retry attempt n (0-indexed),
+// honoring retryAfter when the server provided one.
+func (p RetryPolicy) backoff(n int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		return retryAfter
+	}
+
+	d := float64(p.Base) * pow(p.Factor, n)
+	if d > float64(p.Cap) {
+		d = float64(p.Cap)
+	}
+
+	// full jitter: sleep anywhere between 0 and d
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func pow(base float64, n int) float64 {
+	r := 1.0
+	for i := 0; i < n; i++ {
+		r *= base
+	}
+	return r
+}
+
+// retriableError wraps an error that is safe to retry.
+type retriableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (r *retriableError) Error() string { return r.err.Error() }
+
+// classifyHTTPError decides whether resp/err from an HTTP round trip should
+// be retried. err is non-nil here only for transport-level failures from
+// client.Do (connection refused, timeout, EOF mid-response, ...) - by the
+// time we're here the request was already sent, so every such failure is
+// treated as retriable on purpose, rather than trying to sort "real" network
+// errors from the rest (net.Error doesn't reliably distinguish them: e.g.
+// *url.Error satisfies it unconditionally). 5xx, 408 and 429 responses are
+// retriable; other 4xx responses and successful responses are not.
+func classifyHTTPError(resp *http.Response, err error) *retriableError {
+	if err != nil {
+		return &retriableError{err: err}
+	}
+
+	if resp == nil {
+		return nil
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &retriableError{err: errStatus(resp), retryAfter: retryAfterDuration(resp)}
+	case resp.StatusCode == http.StatusRequestTimeout:
+		return &retriableError{err: errStatus(resp)}
+	case resp.StatusCode >= 500:
+		return &retriableError{err: errStatus(resp)}
+	default:
+		return nil
+	}
+}
+
+func retryAfterDuration(resp *http.Response) time.Duration {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func errStatus(resp *http.Response) error {
+	return &httpStatusError{status: resp.StatusCode}
+}
+
+type httpStatusError struct{ status int }
+
+func (e *httpStatusError) Error() string {
+	return "analysis backend responded with status " + strconv.Itoa(e.status)
+}
+
+// doWithRetry executes req with policy's exponential backoff, rebuilding the
+// request body each attempt via newReq so it can be re-sent after a
+// transient failure.
+func doWithRetry(client *http.Client, policy RetryPolicy, newReq func() (*http.Request, error)) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= policy.MaxRetries; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if re := classifyHTTPError(resp, err); re != nil {
+			lastErr = re
+			if resp != nil {
+				resp.Body.Close()
+			}
+			if attempt == policy.MaxRetries {
+				break
+			}
+			time.Sleep(policy.backoff(attempt, re.retryAfter))
+			continue
+		}
+
+		return resp, err
+	}
+
+	return nil, lastErr
+}
+
+// doRetriable retries fn with policy's backoff, for calls (like the
+// exercism client's) that don't hand back an *http.Response for
+// classifyHTTPError to inspect. fn must return a *retriableError for
+// failures that are safe to retry - e.g. by building its HTTP requests
+// through classifyHTTPError internally - same as doWithRetry does for
+// analysseur. Any other error is treated as permanent and returned
+// immediately, so a 404/401/etc. from exercism fails fast instead of
+// burning through the whole backoff schedule.
+func doRetriable(policy RetryPolicy, fn func() error) error {
+	var lastErr error
+
+	for attempt := 0; attempt <= policy.MaxRetries; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		re, ok := err.(*retriableError)
+		if !ok {
+			return err
+		}
+
+		lastErr = re
+		if attempt == policy.MaxRetries {
+			break
+		}
+		time.Sleep(policy.backoff(attempt, re.retryAfter))
+	}
+
+	return lastErr
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPow(t *testing.T) {
+	cases := []struct {
+		base float64
+		n    int
+		want float64
+	}{
+		{2, 0, 1},
+		{2, 1, 2},
+		{2, 3, 8},
+		{1.5, 2, 2.25},
+	}
+
+	for _, c := range cases {
+		if got := pow(c.base, c.n); got != c.want {
+			t.Errorf("pow(%v, %d) = %v, want %v", c.base, c.n, got, c.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffHonorsRetryAfter(t *testing.T) {
+	p := RetryPolicy{Base: 500 * time.Millisecond, Factor: 2, Cap: 30 * time.Second, MaxRetries: 5}
+
+	if got := p.backoff(0, 10*time.Second); got != 10*time.Second {
+		t.Errorf("backoff with retryAfter = %v, want 10s", got)
+	}
+}
+
+func TestRetryPolicyBackoffCapsDuration(t *testing.T) {
+	p := RetryPolicy{Base: 500 * time.Millisecond, Factor: 2, Cap: 1 * time.Second, MaxRetries: 10}
+
+	// At a high attempt number the exponential growth would far exceed
+	// Cap; the jittered result must never exceed it.
+	if got := p.backoff(20, 0); got > p.Cap {
+		t.Errorf("backoff(20, 0) = %v, want <= cap %v", got, p.Cap)
+	}
+}
+
+func TestClassifyHTTPErrorRetriesTransportErrors(t *testing.T) {
+	re := classifyHTTPError(nil, errors.New("connection refused"))
+	if re == nil {
+		t.Fatal("expected a transport error to be classified as retriable")
+	}
+}
+
+func TestClassifyHTTPErrorRetriableStatuses(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway} {
+		resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+		if re := classifyHTTPError(resp, nil); re == nil {
+			t.Errorf("status %d: expected retriable, got nil", status)
+		}
+	}
+}
+
+func TestClassifyHTTPErrorNonRetriableStatuses(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound} {
+		resp := &http.Response{StatusCode: status, Header: make(http.Header)}
+		if re := classifyHTTPError(resp, nil); re != nil {
+			t.Errorf("status %d: expected non-retriable, got %v", status, re)
+		}
+	}
+}
+
+func TestClassifyHTTPErrorHonorsRetryAfterHeader(t *testing.T) {
+	header := make(http.Header)
+	header.Set("Retry-After", "7")
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: header}
+
+	re := classifyHTTPError(resp, nil)
+	if re == nil {
+		t.Fatal("expected 429 to be retriable")
+	}
+	if re.retryAfter != 7*time.Second {
+		t.Errorf("retryAfter = %v, want 7s", re.retryAfter)
+	}
+}
+
+func TestRetryAfterDurationSeconds(t *testing.T) {
+	header := make(http.Header)
+	header.Set("Retry-After", "5")
+	resp := &http.Response{Header: header}
+
+	if got := retryAfterDuration(resp); got != 5*time.Second {
+		t.Errorf("retryAfterDuration = %v, want 5s", got)
+	}
+}
+
+func TestRetryAfterDurationMissing(t *testing.T) {
+	resp := &http.Response{Header: make(http.Header)}
+
+	if got := retryAfterDuration(resp); got != 0 {
+		t.Errorf("retryAfterDuration = %v, want 0", got)
+	}
+}
+
+func TestDoWithRetrySucceedsAfterTransientFailures(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p := RetryPolicy{Base: time.Millisecond, Factor: 2, Cap: 10 * time.Millisecond, MaxRetries: 5}
+
+	resp, err := doWithRetry(server.Client(), p, func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestDoWithRetryGivesUpAfterMaxRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	p := RetryPolicy{Base: time.Millisecond, Factor: 2, Cap: 10 * time.Millisecond, MaxRetries: 2}
+
+	_, err := doWithRetry(server.Client(), p, func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err == nil {
+		t.Fatal("expected doWithRetry to give up and return an error")
+	}
+	if attempts != 3 { // initial attempt + 2 retries
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestDoWithRetryFailsFastOnNonRetriableStatus(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	p := RetryPolicy{Base: time.Millisecond, Factor: 2, Cap: 10 * time.Millisecond, MaxRetries: 5}
+
+	resp, err := doWithRetry(server.Client(), p, func() (*http.Request, error) {
+		return http.NewRequest("GET", server.URL, nil)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	resp.Body.Close()
+
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (no retries on a non-retriable status)", attempts)
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestDoRetriableSucceedsAfterTransientFailures(t *testing.T) {
+	p := RetryPolicy{Base: time.Millisecond, Factor: 2, Cap: 10 * time.Millisecond, MaxRetries: 3}
+
+	attempts := 0
+	err := doRetriable(p, func() error {
+		attempts++
+		if attempts < 3 {
+			return &retriableError{err: &httpStatusError{status: 503}}
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatalf("doRetriable returned error after eventual success: %s", err)
+	}
+	if attempts != 3 {
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestDoRetriableGivesUpAfterMaxRetries(t *testing.T) {
+	p := RetryPolicy{Base: time.Millisecond, Factor: 2, Cap: 10 * time.Millisecond, MaxRetries: 2}
+
+	attempts := 0
+	err := doRetriable(p, func() error {
+		attempts++
+		return &retriableError{err: &httpStatusError{status: 503}}
+	})
+
+	if err == nil {
+		t.Fatal("expected doRetriable to give up and return an error")
+	}
+	if attempts != 3 { // initial attempt + 2 retries
+		t.Errorf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestDoRetriableFailsFastOnNonRetriableError(t *testing.T) {
+	p := RetryPolicy{Base: time.Millisecond, Factor: 2, Cap: 10 * time.Millisecond, MaxRetries: 5}
+
+	attempts := 0
+	notFound := errors.New("solution " + strconv.Itoa(404) + " not found")
+	err := doRetriable(p, func() error {
+		attempts++
+		return notFound
+	})
+
+	if err != notFound {
+		t.Fatalf("err = %v, want the original non-retriable error returned unchanged", err)
+	}
+	if attempts != 1 {
+		t.Errorf("attempts = %d, want 1 (fail fast, no retries)", attempts)
+	}
+}
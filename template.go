@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+
+	rikkilog "github.com/MalloZup/rikki-/log"
+)
+
+// commentContext is made available to comment bodies via Go's text/template,
+// so a comment can personalize itself, e.g. "Hey {{.User}}, nice work on
+// {{.Exercise}}!".
+type commentContext struct {
+	User     string
+	Exercise string
+	Smell    string
+}
+
+// renderComment executes c's template (compiled once when it was loaded)
+// against ctx. Comments with no template, or whose template fails to
+// execute, are posted as their raw body rather than dropped.
+func renderComment(c *comment, ctx commentContext) []byte {
+	if c.tmpl == nil {
+		return c.Body
+	}
+
+	var buf bytes.Buffer
+	if err := c.tmpl.Execute(&buf, ctx); err != nil {
+		logger.Warn("failed to render comment template, posting raw body", rikkilog.Fields{"error": err.Error()})
+		return c.Body
+	}
+
+	return buf.Bytes()
+}